@@ -0,0 +1,205 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	artifactCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spyglass_artifact_cache_hits",
+		Help: "Number of artifact metadata cache hits, by cache.",
+	}, []string{"cache"})
+	artifactCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spyglass_artifact_cache_misses",
+		Help: "Number of artifact metadata cache misses, by cache.",
+	}, []string{"cache"})
+	artifactCacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spyglass_artifact_cache_evictions",
+		Help: "Number of artifact metadata cache evictions, by cache.",
+	}, []string{"cache"})
+)
+
+func init() {
+	prometheus.MustRegister(artifactCacheHits, artifactCacheMisses, artifactCacheEvictions)
+}
+
+const (
+	// inProgressArtifactTTL is used for artifact metadata belonging to jobs that haven't
+	// finished yet, since their artifact list and file sizes can still change.
+	inProgressArtifactTTL = 30 * time.Second
+	// finishedArtifactTTL is used for artifact metadata belonging to completed jobs, whose
+	// artifacts are immutable.
+	finishedArtifactTTL = 24 * time.Hour
+
+	defaultArtifactCacheCapacity = 10000
+)
+
+// cacheEntry holds a cached value alongside the time at which it should be considered stale.
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// ArtifactCache memoizes artifact name listings and artifact sizes so that repeated
+// Spyglass page loads for the same job don't re-list the backend or re-probe every
+// artifact. Concurrent misses for the same key are coalesced via singleflight so a
+// thundering herd on a popular job produces exactly one backend call.
+type ArtifactCache struct {
+	names   *lru.Cache
+	sizes   *lru.Cache
+	indexes *lru.Cache
+	group   singleflight.Group
+}
+
+// NewArtifactCache creates an ArtifactCache whose names, sizes and layer-index caches
+// each hold up to capacity entries. A non-positive capacity falls back to
+// defaultArtifactCacheCapacity.
+func NewArtifactCache(capacity int) *ArtifactCache {
+	if capacity <= 0 {
+		capacity = defaultArtifactCacheCapacity
+	}
+	names, err := lru.NewWithEvict(capacity, func(key interface{}, value interface{}) {
+		artifactCacheEvictions.WithLabelValues("names").Inc()
+	})
+	if err != nil {
+		// Only returns an error for a non-positive size, which we've already guarded against.
+		panic(err)
+	}
+	sizes, err := lru.NewWithEvict(capacity, func(key interface{}, value interface{}) {
+		artifactCacheEvictions.WithLabelValues("sizes").Inc()
+	})
+	if err != nil {
+		panic(err)
+	}
+	indexes, err := lru.NewWithEvict(capacity, func(key interface{}, value interface{}) {
+		artifactCacheEvictions.WithLabelValues("indexes").Inc()
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &ArtifactCache{names: names, sizes: sizes, indexes: indexes}
+}
+
+func namesCacheKey(keyType, key string) string {
+	return fmt.Sprintf("%s/%s", keyType, key)
+}
+
+func sizeCacheKey(keyType, key, name string) string {
+	return fmt.Sprintf("%s/%s/%s", keyType, key, name)
+}
+
+// artifactNames returns the cached artifact name listing for (keyType, key), calling fetch
+// to populate the cache on a miss or after ttl has elapsed.
+func (c *ArtifactCache) artifactNames(keyType, key string, ttl time.Duration, fetch func() ([]string, error)) ([]string, error) {
+	cacheKey := namesCacheKey(keyType, key)
+	if v, ok := c.names.Get(cacheKey); ok {
+		entry := v.(cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			artifactCacheHits.WithLabelValues("names").Inc()
+			return entry.value.([]string), nil
+		}
+	}
+	artifactCacheMisses.WithLabelValues("names").Inc()
+
+	v, err, _ := c.group.Do("names/"+cacheKey, func() (interface{}, error) {
+		// A flight that finished after the check above may already have filled the entry.
+		if v, ok := c.names.Get(cacheKey); ok && time.Now().Before(v.(cacheEntry).expiresAt) {
+			return v.(cacheEntry).value, nil
+		}
+		names, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.names.Add(cacheKey, cacheEntry{value: names, expiresAt: time.Now().Add(ttl)})
+		return names, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+// artifactSize returns the cached size for (keyType, key, name), calling fetch to populate
+// the cache on a miss or after ttl has elapsed.
+func (c *ArtifactCache) artifactSize(keyType, key, name string, ttl time.Duration, fetch func() (int64, error)) (int64, error) {
+	cacheKey := sizeCacheKey(keyType, key, name)
+	if v, ok := c.sizes.Get(cacheKey); ok {
+		entry := v.(cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			artifactCacheHits.WithLabelValues("sizes").Inc()
+			return entry.value.(int64), nil
+		}
+	}
+	artifactCacheMisses.WithLabelValues("sizes").Inc()
+
+	v, err, _ := c.group.Do("sizes/"+cacheKey, func() (interface{}, error) {
+		if v, ok := c.sizes.Get(cacheKey); ok && time.Now().Before(v.(cacheEntry).expiresAt) {
+			return v.(cacheEntry).value, nil
+		}
+		size, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.sizes.Add(cacheKey, cacheEntry{value: size, expiresAt: time.Now().Add(ttl)})
+		return size, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+// ociLayerIndex returns the cached layer index for key, calling fetch to build and cache it
+// on a miss or after ttl has elapsed. This bounds and expires the index cache the same way
+// artifactNames/artifactSize do, rather than letting callers grow an unbounded map of their
+// own: unlike gcs/s3/az keys, oci keys are indexed once per image pull, so without an LRU
+// bound and a TTL every distinct image ever browsed (and every re-push of a floating tag)
+// would otherwise live forever.
+func (c *ArtifactCache) ociLayerIndex(key string, ttl time.Duration, fetch func() (*ociLayerIndex, error)) (*ociLayerIndex, error) {
+	cacheKey := "oci/" + key
+	if v, ok := c.indexes.Get(cacheKey); ok {
+		entry := v.(cacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			artifactCacheHits.WithLabelValues("indexes").Inc()
+			return entry.value.(*ociLayerIndex), nil
+		}
+	}
+	artifactCacheMisses.WithLabelValues("indexes").Inc()
+
+	v, err, _ := c.group.Do("indexes/"+cacheKey, func() (interface{}, error) {
+		if v, ok := c.indexes.Get(cacheKey); ok && time.Now().Before(v.(cacheEntry).expiresAt) {
+			return v.(cacheEntry).value, nil
+		}
+		index, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		c.indexes.Add(cacheKey, cacheEntry{value: index, expiresAt: time.Now().Add(ttl)})
+		return index, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*ociLayerIndex), nil
+}
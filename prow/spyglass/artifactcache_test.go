@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestArtifactCacheNamesTTLExpiry(t *testing.T) {
+	cache := NewArtifactCache(10)
+
+	var calls int
+	fetch := func() ([]string, error) {
+		calls++
+		return []string{fmt.Sprintf("build-log-%d.txt", calls)}, nil
+	}
+
+	names, err := cache.artifactNames("gcs", "some/job/123", time.Hour, fetch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 call after first fetch, got %d", calls)
+	}
+
+	if again, err := cache.artifactNames("gcs", "some/job/123", time.Hour, fetch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if calls != 1 {
+		t.Fatalf("expected cache hit to avoid a second call, got %d calls", calls)
+	} else if again[0] != names[0] {
+		t.Fatalf("expected cached value %v, got %v", names, again)
+	}
+
+	// A negative TTL is already expired, so the next read should miss and re-fetch.
+	cache2 := NewArtifactCache(10)
+	if _, err := cache2.artifactNames("gcs", "some/job/123", -time.Second, fetch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cache2.artifactNames("gcs", "some/job/123", -time.Second, fetch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected an expired entry to be re-fetched, got %d total calls", calls)
+	}
+}
+
+func TestArtifactCacheSizeKeyedPerName(t *testing.T) {
+	cache := NewArtifactCache(10)
+
+	sizes := map[string]int64{"a.txt": 1, "b.txt": 2}
+	var calls int
+	fetch := func(name string) func() (int64, error) {
+		return func() (int64, error) {
+			calls++
+			return sizes[name], nil
+		}
+	}
+
+	for name, want := range sizes {
+		got, err := cache.artifactSize("gcs", "some/job/123", name, time.Hour, fetch(name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("artifactSize(%q) = %d, want %d", name, got, want)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected one fetch per distinct name, got %d", calls)
+	}
+
+	if _, err := cache.artifactSize("gcs", "some/job/123", "a.txt", time.Hour, fetch("a.txt")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected cache hit for an already-fetched name, got %d calls", calls)
+	}
+}
+
+func TestArtifactCacheSingleflightCoalescesConcurrentMisses(t *testing.T) {
+	cache := NewArtifactCache(10)
+
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() ([]string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []string{"build-log.txt"}, nil
+	}
+
+	const concurrency = 10
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			if _, err := cache.artifactNames("gcs", "some/job/123", time.Hour, fetch); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected concurrent misses for the same key to coalesce into 1 backend call, got %d", got)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// ArtifactFetcher knows how to list and fetch artifacts for a single storage
+// backend, identified by the key-type prefix of a Spyglass src (e.g. "gcs",
+// "s3", "az"). Implementations are registered against a Spyglass instance
+// via RegisterArtifactFetcher so that splitSrc-derived key types can be
+// dispatched without the rest of the package knowing which backend serves
+// them.
+type ArtifactFetcher interface {
+	// artifacts lists the names of all artifacts available under key.
+	artifacts(key string) ([]string, error)
+	// artifact returns a handle to the named artifact under key. The artifact
+	// itself is not read until the handle is used; the OCI backend may first
+	// pull and index the image that holds it.
+	artifact(key, name string, sizeLimit int64) (lenses.Artifact, error)
+}
+
+// RegisterArtifactFetcher makes fetcher available for srcs whose key type is
+// keyType. It is expected to be called once per backend when the Spyglass is
+// constructed; a later call for the same keyType replaces the previous
+// fetcher.
+func (s *Spyglass) RegisterArtifactFetcher(keyType string, fetcher ArtifactFetcher) {
+	if s.artifactFetchers == nil {
+		s.artifactFetchers = map[string]ArtifactFetcher{}
+	}
+	s.artifactFetchers[keyType] = fetcher
+}
+
+// registerDefaultFetchers wires up the backends that don't need any additional,
+// deployment-specific configuration to be usable: GCS (via the pre-existing
+// GCSArtifactFetcher field, so plain "gcs/..." srcs keep working without every caller
+// that constructs a Spyglass having to know about the new registry) and OCI (which only
+// needs the ambient docker keychain). S3 and Azure Blob require credentials/clients that
+// are specific to a given Prow deployment, so those are expected to be registered
+// explicitly by whatever constructs the Spyglass, via RegisterArtifactFetcher.
+func (s *Spyglass) registerDefaultFetchers() {
+	s.fetcherInitOnce.Do(func() {
+		if s.GCSArtifactFetcher != nil {
+			s.RegisterArtifactFetcher(gcsKeyType, s.GCSArtifactFetcher)
+		}
+		s.RegisterArtifactFetcher(ociKeyType, NewOCIArtifactFetcher(s.cache()))
+	})
+}
+
+// fetcherForKeyType returns the backend registered for keyType, or an error
+// if no backend has been registered for it.
+func (s *Spyglass) fetcherForKeyType(keyType string) (ArtifactFetcher, error) {
+	s.registerDefaultFetchers()
+	fetcher, ok := s.artifactFetchers[keyType]
+	if !ok {
+		return nil, fmt.Errorf("no artifact fetcher registered for key type %q", keyType)
+	}
+	return fetcher, nil
+}
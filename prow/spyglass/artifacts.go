@@ -17,33 +17,74 @@ limitations under the License.
 package spyglass
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 )
 
+const (
+	gcsKeyType   = "gcs"
+	prowKeyType  = "prow"
+	s3KeyType    = "s3"
+	azureKeyType = "az"
+	ociKeyType   = "oci"
+
+	// defaultArtifactFetchWorkers bounds how many artifact size probes
+	// FetchArtifacts runs concurrently when the Spyglass was not constructed
+	// with an explicit worker count. The pod-log fallback is fetched on its
+	// own, after all probes have finished.
+	defaultArtifactFetchWorkers = 16
+	// artifactFetchTimeout bounds how long artifacts in a single FetchArtifacts
+	// call may wait for a free worker; artifacts still waiting when it expires
+	// are skipped. A probe that has already started is not cancelled.
+	artifactFetchTimeout = 30 * time.Second
+)
+
+// artifactFetchWorkers returns the configured concurrency for artifact metadata fetches,
+// falling back to defaultArtifactFetchWorkers if the Spyglass wasn't given one.
+func (s *Spyglass) artifactFetchWorkers() int {
+	if s.ArtifactWorkers > 0 {
+		return s.ArtifactWorkers
+	}
+	return defaultArtifactFetchWorkers
+}
+
+// cache returns the Spyglass's ArtifactCache, lazily constructing one with the default
+// capacity if it wasn't supplied at construction time.
+func (s *Spyglass) cache() *ArtifactCache {
+	if s.ArtifactCache == nil {
+		s.ArtifactCache = NewArtifactCache(defaultArtifactCacheCapacity)
+	}
+	return s.ArtifactCache
+}
+
 // ListArtifacts gets the names of all artifacts available from the given source
 func (s *Spyglass) ListArtifacts(src string) ([]string, error) {
-	keyType, key, err := splitSrc(src)
+	ref, err := s.ParseSrc(src)
 	if err != nil {
-		return []string{}, fmt.Errorf("error parsing src: %v", err)
-	}
-	gcsKey := ""
-	switch keyType {
-	case gcsKeyType:
-		gcsKey = key
-	case prowKeyType:
-		if gcsKey, err = s.prowToGCS(key); err != nil {
-			logrus.Warningf("Failed to get gcs source for prow job: %v", err)
-		}
-	default:
-		return nil, fmt.Errorf("Unrecognized key type for src: %v", src)
+		return nil, fmt.Errorf("error parsing src: %v", err)
+	}
+
+	fetcher, keyType, key, ttl, err := ref.Resolve()
+	if err != nil {
+		logrus.Warningf("Failed to get storage source for src %q: %v", src, err)
+	}
+
+	artifactNames := []string{}
+	if fetcher != nil {
+		artifactNames, err = s.cache().artifactNames(keyType, key, ttl, func() ([]string, error) {
+			return fetcher.artifacts(key)
+		})
 	}
 
-	artifactNames, err := s.GCSArtifactFetcher.artifacts(gcsKey)
 	logFound := false
 	for _, name := range artifactNames {
 		if name == "build-log.txt" {
@@ -53,7 +94,7 @@ func (s *Spyglass) ListArtifacts(src string) ([]string, error) {
 	}
 
 	if err != nil {
-		logrus.Warningf("Failed to list artifacts for prow job: %v", err)
+		logrus.Warningf("Failed to list artifacts for src %q: %v", src, err)
 	}
 
 	if err != nil || !logFound {
@@ -62,26 +103,73 @@ func (s *Spyglass) ListArtifacts(src string) ([]string, error) {
 	return artifactNames, nil
 }
 
-// prowToGCS returns the GCS key corresponding to the given prow key
-func (s *Spyglass) prowToGCS(prowKey string) (string, error) {
+// fetcherForSrc resolves a (keyType, key) pair, as produced by splitSrc, to the backend that
+// serves it, that backend's key type, the key it expects, and the TTL that metadata for it
+// should be cached under. The returned key type is always the resolved storage backend's key
+// type rather than keyType itself, so that a "prow/..." src and a "gcs/..." src resolving to
+// the same underlying storage key share one cache entry instead of two. For prow srcs the
+// underlying storage backend is looked up via prowToStorage; for everything else the key type
+// is dispatched to directly via the registry and, since a bare storage key gives no
+// indication the job is still running, metadata is treated as immutable.
+func (s *Spyglass) fetcherForSrc(keyType, key string) (ArtifactFetcher, string, string, time.Duration, error) {
+	if keyType == prowKeyType {
+		return s.prowToStorage(key)
+	}
+	fetcher, err := s.fetcherForKeyType(keyType)
+	if err != nil {
+		return nil, "", "", 0, err
+	}
+	return fetcher, keyType, key, finishedArtifactTTL, nil
+}
+
+// prowToStorage returns the storage backend, its key type, the key within it, and the cache
+// TTL corresponding to the given prow key, based on the job's configured storage URL and
+// whether the job has finished.
+func (s *Spyglass) prowToStorage(prowKey string) (ArtifactFetcher, string, string, time.Duration, error) {
 	parsed := strings.Split(prowKey, "/")
 	if len(parsed) != 2 {
-		return "", fmt.Errorf("Could not get GCS src: prow src %q incorrectly formatted", prowKey)
+		return nil, "", "", 0, fmt.Errorf("could not get storage src: prow src %q incorrectly formatted", prowKey)
 	}
 	jobName := parsed[0]
 	buildID := parsed[1]
 
 	job, err := s.jobAgent.GetProwJob(jobName, buildID)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get prow job from src %q: %v", prowKey, err)
+		return nil, "", "", 0, fmt.Errorf("failed to get prow job from src %q: %v", prowKey, err)
 	}
 
 	url := job.Status.URL
 	prefix := s.config().Plank.JobURLPrefix
 	if !strings.HasPrefix(url, prefix) {
-		return "", fmt.Errorf("unexpected job URL %q when finding GCS path: expected something starting with %q", url, prefix)
+		return nil, "", "", 0, fmt.Errorf("unexpected job URL %q when finding storage path: expected something starting with %q", url, prefix)
+	}
+	key := url[len(prefix):]
+
+	keyType := keyTypeForStorageKey(key)
+	fetcher, err := s.fetcherForKeyType(keyType)
+	if err != nil {
+		return nil, "", "", 0, err
+	}
+
+	ttl := finishedArtifactTTL
+	if job.Status.State == prowapi.PendingState || job.Status.State == prowapi.TriggeredState {
+		ttl = inProgressArtifactTTL
+	}
+	return fetcher, keyType, key, ttl, nil
+}
+
+// keyTypeForStorageKey infers the registered key type from the scheme of a
+// storage key, defaulting to gcs for backwards compatibility with existing
+// job URLs that carry no scheme.
+func keyTypeForStorageKey(key string) string {
+	switch {
+	case strings.HasPrefix(key, "s3://"):
+		return s3KeyType
+	case strings.HasPrefix(key, "az://"):
+		return azureKeyType
+	default:
+		return gcsKeyType
 	}
-	return url[len(prefix):], nil
 }
 
 // FetchArtifacts constructs and returns Artifact objects for each artifact name in the list.
@@ -89,58 +177,76 @@ func (s *Spyglass) prowToGCS(prowKey string) (string, error) {
 func (s *Spyglass) FetchArtifacts(src string, podName string, sizeLimit int64, artifactNames []string) ([]lenses.Artifact, error) {
 	artStart := time.Now()
 	arts := []lenses.Artifact{}
-	keyType, key, err := splitSrc(src)
+	ref, err := s.ParseSrc(src)
 	if err != nil {
 		return arts, fmt.Errorf("error parsing src: %v", err)
 	}
-	gcsKey := ""
-	jobName := ""
-	buildID := ""
-	switch keyType {
-	case gcsKeyType:
-		gcsKey = strings.TrimSuffix(key, "/")
-		parts := strings.Split(gcsKey, "/")
-		if len(parts) < 2 {
-			logrus.WithField("gcs key", gcsKey).Warningf("invalid gcs key")
-		} else {
-			jobName = parts[len(parts)-2]
-			buildID = parts[len(parts)-1]
-		}
-	case prowKeyType:
-		parts := strings.Split(key, "/")
-		if len(parts) != 2 {
-			return arts, fmt.Errorf("key %q incorrectly formatted", key)
-		}
-		jobName = parts[0]
-		buildID = parts[1]
-		if gcsKey, err = s.prowToGCS(key); err != nil {
-			logrus.Warningln(err)
-		}
-	default:
-		return nil, fmt.Errorf("Invalid src: %v", src)
+	jobName, buildID := ref.JobName, ref.BuildID
+
+	fetcher, keyType, key, ttl, err := ref.Resolve()
+	if err != nil {
+		logrus.Warningf("Failed to get storage source for src %q: %v", src, err)
 	}
 
-	podLogNeeded := false
-	for _, name := range artifactNames {
-		art, err := s.GCSArtifactFetcher.artifact(gcsKey, name, sizeLimit)
-		if err == nil {
-			// Actually try making a request, because calling GCSArtifactFetcher.artifact does no I/O.
-			// (these files are being explicitly requested and so will presumably soon be accessed, so
-			// the extra network I/O should not be too problematic).
-			_, err = art.Size()
-		}
-		if err != nil {
-			if name == "build-log.txt" {
-				podLogNeeded = true
+	ctx, cancel := context.WithTimeout(context.Background(), artifactFetchTimeout)
+	defer cancel()
+
+	fetched := make([]lenses.Artifact, len(artifactNames))
+	sem := make(chan struct{}, s.artifactFetchWorkers())
+	var podLogNeeded int32
+	g, ctx := errgroup.WithContext(ctx)
+	for i, name := range artifactNames {
+		i, name := i, name
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				if name == "build-log.txt" {
+					atomic.StoreInt32(&podLogNeeded, 1)
+				} else {
+					logrus.Warningf("Timed out waiting to fetch artifact %s", name)
+				}
+				return ctx.Err()
+			}
+			defer func() { <-sem }()
+
+			fetchStart := time.Now()
+			var art lenses.Artifact
+			var err error
+			if fetcher != nil {
+				art, err = fetcher.artifact(key, name, sizeLimit)
+				if err == nil {
+					// Actually try making a request, because calling artifact does not probe the artifact itself.
+					// (these files are being explicitly requested and so will presumably soon be accessed, so
+					// the extra network I/O should not be too problematic).
+					_, err = s.cache().artifactSize(keyType, key, name, ttl, art.Size)
+				}
 			} else {
-				logrus.Errorf("Failed to fetch artifact %s: %v", name, err)
+				err = fmt.Errorf("no storage backend available")
+			}
+			logrus.WithField("artifact", name).WithField("duration", time.Since(fetchStart)).Debug("Fetched artifact metadata")
+			if err != nil {
+				if name == "build-log.txt" {
+					atomic.StoreInt32(&podLogNeeded, 1)
+				} else {
+					logrus.Errorf("Failed to fetch artifact %s: %v", name, err)
+				}
+				return nil
 			}
-			continue
+			fetched[i] = art
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		logrus.Warningf("Fetching artifacts for %v did not complete: %v", src, err)
+	}
+	for _, art := range fetched {
+		if art != nil {
+			arts = append(arts, art)
 		}
-		arts = append(arts, art)
 	}
 
-	if podLogNeeded {
+	if atomic.LoadInt32(&podLogNeeded) == 1 {
 		art, err := s.PodLogArtifactFetcher.artifact(jobName, buildID, sizeLimit)
 		if err != nil {
 			logrus.Errorf("Failed to fetch pod log: %v", err)
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// AzureBlobArtifactFetcher contains information used for fetching artifacts from Azure Blob
+// Storage, analogous to GCSArtifactFetcher.
+type AzureBlobArtifactFetcher struct {
+	pipeline azblob.Pipeline
+}
+
+// NewAzureBlobArtifactFetcher creates a new ArtifactFetcher backed by the given Azure pipeline.
+func NewAzureBlobArtifactFetcher(pipeline azblob.Pipeline) *AzureBlobArtifactFetcher {
+	return &AzureBlobArtifactFetcher{pipeline: pipeline}
+}
+
+// azureContainerURLAndPrefix splits an "az://account/container/prefix" key into the
+// container's URL and the blob name prefix within it.
+func (af *AzureBlobArtifactFetcher) azureContainerURLAndPrefix(key string) (azblob.ContainerURL, string, error) {
+	trimmed := strings.TrimPrefix(key, "az://")
+	parts := strings.SplitN(trimmed, "/", 3)
+	if len(parts) != 3 {
+		return azblob.ContainerURL{}, "", fmt.Errorf("invalid azure key %q: expected az://<account>/<container>/<prefix>", key)
+	}
+	account, container, prefix := parts[0], parts[1], parts[2]
+	u, err := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container))
+	if err != nil {
+		return azblob.ContainerURL{}, "", fmt.Errorf("invalid azure account %q: %v", account, err)
+	}
+	return azblob.NewContainerURL(*u, af.pipeline), prefix, nil
+}
+
+// artifacts lists all artifact names under the given az key.
+func (af *AzureBlobArtifactFetcher) artifacts(key string) ([]string, error) {
+	containerURL, prefix, err := af.azureContainerURLAndPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var names []string
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{
+			Prefix: prefix + "/",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing artifacts for key %q: %v", key, err)
+		}
+		for _, blob := range resp.Segment.BlobItems {
+			names = append(names, strings.TrimPrefix(blob.Name, prefix+"/"))
+		}
+		marker = resp.NextMarker
+	}
+	return names, nil
+}
+
+// artifact returns the Artifact handle for the given key and artifact name. No I/O is done.
+func (af *AzureBlobArtifactFetcher) artifact(key, artifactName string, sizeLimit int64) (lenses.Artifact, error) {
+	containerURL, prefix, err := af.azureContainerURLAndPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	blobURL := containerURL.NewBlobURL(prefix + "/" + artifactName)
+	return NewAzureBlobArtifact(blobURL, artifactName, sizeLimit), nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ociFileLocation records which layer a file lives in, so a single file can be read back
+// out of that layer without re-walking the whole image.
+type ociFileLocation struct {
+	layer v1.Layer
+	name  string
+	size  int64
+}
+
+// ociLayerIndex maps file names to their location across all of an image's layers. Later
+// layers shadow earlier ones, matching normal OCI overlay semantics. It also caches each
+// layer's decompressed tar bytes the first time any file within it is read: gzip-compressed
+// layers can't be read at an arbitrary byte offset without an index of their own (unlike
+// S3/Azure blobs), so the cheapest way to honor sizeLimit without re-pulling and
+// re-decompressing the whole layer on every read is to decompress it once and reuse it.
+type ociLayerIndex struct {
+	files map[string]ociFileLocation
+
+	mu         sync.Mutex
+	layerBytes map[string][]byte // keyed by layer digest
+}
+
+// newOCILayerIndex walks every layer of img once and records where each file lives.
+func newOCILayerIndex(img v1.Image) (*ociLayerIndex, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("error getting image layers: %v", err)
+	}
+
+	index := &ociLayerIndex{files: map[string]ociFileLocation{}, layerBytes: map[string][]byte{}}
+	for _, layer := range layers {
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			return nil, fmt.Errorf("error reading layer: %v", err)
+		}
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rc.Close()
+				return nil, fmt.Errorf("error reading layer tar: %v", err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			index.files[hdr.Name] = ociFileLocation{
+				layer: layer,
+				name:  hdr.Name,
+				size:  hdr.Size,
+			}
+		}
+		rc.Close()
+	}
+	return index, nil
+}
+
+// fileBytes returns the uncompressed content of loc, decompressing and caching its whole
+// layer on the first call for that layer and reusing the cached bytes afterwards.
+func (idx *ociLayerIndex) fileBytes(loc ociFileLocation) ([]byte, error) {
+	digest, err := loc.layer.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("error getting layer digest for %q: %v", loc.name, err)
+	}
+	digestKey := digest.String()
+
+	idx.mu.Lock()
+	layer, cached := idx.layerBytes[digestKey]
+	idx.mu.Unlock()
+
+	if !cached {
+		rc, err := loc.layer.Uncompressed()
+		if err != nil {
+			return nil, fmt.Errorf("error reading layer for %q: %v", loc.name, err)
+		}
+		layer, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing layer for %q: %v", loc.name, err)
+		}
+		idx.mu.Lock()
+		idx.layerBytes[digestKey] = layer
+		idx.mu.Unlock()
+	}
+
+	tr := tar.NewReader(bytes.NewReader(layer))
+	if err := seekToFile(tr, loc.name); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(tr)
+}
+
+// OCIArtifact represents a single file found within the layers of an OCI image.
+type OCIArtifact struct {
+	index     *ociLayerIndex
+	loc       ociFileLocation
+	jobPath   string
+	sizeLimit int64
+}
+
+// NewOCIArtifact returns a new OCIArtifact. No I/O is performed until the artifact is read.
+func NewOCIArtifact(index *ociLayerIndex, loc ociFileLocation, jobPath string, sizeLimit int64) *OCIArtifact {
+	return &OCIArtifact{index: index, loc: loc, jobPath: jobPath, sizeLimit: sizeLimit}
+}
+
+// Size returns the uncompressed size of the file, as recorded in the layer's tar header.
+func (a *OCIArtifact) Size() (int64, error) {
+	return a.loc.size, nil
+}
+
+// JobPath returns the path of the artifact within the job's artifact directory.
+func (a *OCIArtifact) JobPath() string {
+	return a.jobPath
+}
+
+// CanonicalLink returns the digest of the layer backing this file, since individual files
+// within an OCI layer have no addressable URL of their own.
+func (a *OCIArtifact) CanonicalLink() string {
+	digest, err := a.loc.layer.Digest()
+	if err != nil {
+		return ""
+	}
+	return digest.String()
+}
+
+// ReadAt reads len(p) bytes of the file starting at offset off.
+func (a *OCIArtifact) ReadAt(p []byte, off int64) (int, error) {
+	content, err := a.index.fileBytes(a.loc)
+	if err != nil {
+		return 0, err
+	}
+	if off >= int64(len(content)) {
+		return 0, io.EOF
+	}
+	n := copy(p, content[off:])
+	if n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+// ReadAtMost reads at most n bytes from the start of the file.
+func (a *OCIArtifact) ReadAtMost(n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := a.ReadAt(buf, 0)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// ReadAll reads the entire file into memory, subject to sizeLimit.
+func (a *OCIArtifact) ReadAll() ([]byte, error) {
+	if a.sizeLimit > 0 && a.loc.size > a.sizeLimit {
+		return nil, fmt.Errorf("artifact %q exceeds size limit (%d > %d)", a.jobPath, a.loc.size, a.sizeLimit)
+	}
+	return a.ReadAtMost(a.loc.size)
+}
+
+// seekToFile advances tr to the entry named name, or returns an error if it isn't found.
+func seekToFile(tr *tar.Reader, name string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("file %q not found in layer", name)
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Name == name {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// OCIArtifactFetcher fetches artifacts published as files in the layers of an OCI image,
+// e.g. a ko/crane-pushed build-result image. Keys are of the form
+// "registry/repo@digest" or "registry/repo:tag".
+//
+// Pulling and indexing a manifest requires fetching every layer, so the result is cached in
+// the same bounded, TTL'd ArtifactCache used for artifact names and sizes: without this,
+// ListArtifacts followed by a FetchArtifacts over N artifact names would otherwise re-pull
+// and re-walk the whole image N+1 times for a single page load, and an unbounded cache of
+// our own would never evict or notice a floating tag had been re-pushed.
+type OCIArtifactFetcher struct {
+	cache *ArtifactCache
+}
+
+// NewOCIArtifactFetcher creates a new ArtifactFetcher backed by go-containerregistry,
+// authenticating against registries with the standard docker keychain. cache is used to
+// memoize each key's layer index; pass the same ArtifactCache the Spyglass uses elsewhere.
+func NewOCIArtifactFetcher(cache *ArtifactCache) *OCIArtifactFetcher {
+	return &OCIArtifactFetcher{cache: cache}
+}
+
+// artifacts lists the names of all files present across the layers of the image at key.
+func (af *OCIArtifactFetcher) artifacts(key string) ([]string, error) {
+	index, err := af.indexFor(key)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(index.files))
+	for fileName := range index.files {
+		names = append(names, fileName)
+	}
+	return names, nil
+}
+
+// artifact returns the Artifact handle for the named file within the image at key. On a
+// layer-index cache miss this pulls and indexes the image; the file's content is not read
+// until the artifact is read.
+func (af *OCIArtifactFetcher) artifact(key, artifactName string, sizeLimit int64) (lenses.Artifact, error) {
+	index, err := af.indexFor(key)
+	if err != nil {
+		return nil, err
+	}
+	loc, ok := index.files[artifactName]
+	if !ok {
+		return nil, fmt.Errorf("file %q not found in image %q", artifactName, key)
+	}
+	return NewOCIArtifact(index, loc, artifactName, sizeLimit), nil
+}
+
+// indexFor returns the cached layer index for key, building it at most once even if called
+// concurrently for the same key (the cache coalesces misses via singleflight). oci keys are
+// normally digest-pinned and therefore immutable, but the "registry/repo:tag" form this
+// fetcher also accepts can float, so the index is still bounded by finishedArtifactTTL rather
+// than cached forever.
+func (af *OCIArtifactFetcher) indexFor(key string) (*ociLayerIndex, error) {
+	return af.cache.ociLayerIndex(key, finishedArtifactTTL, func() (*ociLayerIndex, error) {
+		img, err := af.image(key)
+		if err != nil {
+			return nil, err
+		}
+		index, err := newOCILayerIndex(img)
+		if err != nil {
+			return nil, fmt.Errorf("error indexing image layers for %q: %v", key, err)
+		}
+		return index, nil
+	})
+}
+
+// image resolves an OCI key to its manifest using the standard docker keychain, so private
+// registries configured via `docker login` (or an equivalent credential helper) work.
+func (af *OCIArtifactFetcher) image(key string) (v1.Image, error) {
+	ref, err := name.ParseReference(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oci key %q: %v", key, err)
+	}
+	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("error pulling manifest for %q: %v", key, err)
+	}
+	return img, nil
+}
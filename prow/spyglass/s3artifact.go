@@ -0,0 +1,127 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Artifact represents an artifact stored in an S3-compatible bucket, fetched lazily and
+// cached for the lifetime of a single Spyglass request.
+type S3Artifact struct {
+	fetcher   *S3ArtifactFetcher
+	bucket    string
+	key       string
+	jobPath   string
+	sizeLimit int64
+
+	once sync.Once
+	size int64
+	err  error
+}
+
+// NewS3Artifact returns a new S3Artifact. No I/O is performed until the artifact is read.
+func NewS3Artifact(fetcher *S3ArtifactFetcher, bucket, key, jobPath string, sizeLimit int64) *S3Artifact {
+	return &S3Artifact{
+		fetcher:   fetcher,
+		bucket:    bucket,
+		key:       key,
+		jobPath:   jobPath,
+		sizeLimit: sizeLimit,
+	}
+}
+
+func (a *S3Artifact) head() {
+	a.once.Do(func() {
+		out, err := a.fetcher.client.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(a.bucket),
+			Key:    aws.String(a.key),
+		})
+		if err != nil {
+			a.err = fmt.Errorf("error getting attributes for key %q: %v", a.key, err)
+			return
+		}
+		a.size = aws.Int64Value(out.ContentLength)
+	})
+	return
+}
+
+// Size returns the size of the artifact in bytes, making a HEAD request for it if necessary.
+func (a *S3Artifact) Size() (int64, error) {
+	a.head()
+	return a.size, a.err
+}
+
+// JobPath returns the path of the artifact within the job's artifact directory.
+func (a *S3Artifact) JobPath() string {
+	return a.jobPath
+}
+
+// CanonicalLink returns the S3 URI of the artifact.
+func (a *S3Artifact) CanonicalLink() string {
+	return fmt.Sprintf("s3://%s/%s", a.bucket, a.key)
+}
+
+// ReadAt reads len(p) bytes of the artifact starting at offset off.
+func (a *S3Artifact) ReadAt(p []byte, off int64) (int, error) {
+	rng := fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1)
+	buf := aws.NewWriteAtBuffer(p)
+	n, err := a.fetcher.downloader.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key),
+		Range:  aws.String(rng),
+	})
+	return int(n), err
+}
+
+// ReadAtMost reads at most n bytes from the start of the artifact.
+func (a *S3Artifact) ReadAtMost(n int64) ([]byte, error) {
+	buf := aws.NewWriteAtBuffer(make([]byte, 0, n))
+	_, err := a.fetcher.downloader.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", n-1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadAll reads the entire artifact into memory, subject to sizeLimit.
+func (a *S3Artifact) ReadAll() ([]byte, error) {
+	size, err := a.Size()
+	if err != nil {
+		return nil, err
+	}
+	if a.sizeLimit > 0 && size > a.sizeLimit {
+		return nil, fmt.Errorf("artifact %q exceeds size limit (%d > %d)", a.key, size, a.sizeLimit)
+	}
+	buf := aws.NewWriteAtBuffer(make([]byte, 0, size))
+	_, err = a.fetcher.downloader.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// S3ArtifactFetcher contains information used for fetching artifacts from S3-compatible
+// object storage, analogous to GCSArtifactFetcher.
+type S3ArtifactFetcher struct {
+	client     *s3.S3
+	downloader *s3manager.Downloader
+}
+
+// NewS3ArtifactFetcher creates a new ArtifactFetcher backed by the given S3 client.
+func NewS3ArtifactFetcher(client *s3.S3) *S3ArtifactFetcher {
+	return &S3ArtifactFetcher{
+		client:     client,
+		downloader: s3manager.NewDownloaderWithClient(client),
+	}
+}
+
+// s3BucketAndPrefix splits an "s3://bucket/prefix" key into its bucket and prefix parts.
+func s3BucketAndPrefix(key string) (bucket, prefix string, err error) {
+	trimmed := strings.TrimPrefix(key, "s3://")
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid s3 key %q: expected s3://<bucket>/<prefix>", key)
+	}
+	return parts[0], parts[1], nil
+}
+
+// artifacts lists all artifact names under the given s3 key.
+func (af *S3ArtifactFetcher) artifacts(key string) ([]string, error) {
+	bucket, prefix, err := s3BucketAndPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	err = af.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix + "/"),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.StringValue(obj.Key), prefix+"/"))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts for key %q: %v", key, err)
+	}
+	return names, nil
+}
+
+// artifact returns the Artifact handle for the given key and artifact name. No I/O is done.
+func (af *S3ArtifactFetcher) artifact(key, artifactName string, sizeLimit int64) (lenses.Artifact, error) {
+	bucket, prefix, err := s3BucketAndPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	objectName := prefix + "/" + artifactName
+	return NewS3Artifact(af, bucket, objectName, artifactName, sizeLimit), nil
+}
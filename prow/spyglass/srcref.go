@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SrcRef is a validated Spyglass src, as passed to ListArtifacts and FetchArtifacts.
+// It is the single place JobName/BuildID are extracted and the key-type is checked
+// against the registered backends, so every caller gets the same error messages
+// instead of each re-parsing src with splitSrc and repeating the strings.Split dance.
+//
+// Resolving the underlying storage backend (which, for a prow src, requires a
+// prowjob lookup) is deferred until first needed and then cached, via Resolve/GCSKey.
+type SrcRef struct {
+	// KeyType is the key-type prefix of the original src (e.g. "gcs", "prow").
+	KeyType string
+	// Key is the unresolved key, i.e. everything in src after the key-type prefix.
+	Key string
+	// JobName and BuildID are extracted from Key for both the gcs and prow key-types.
+	JobName string
+	BuildID string
+
+	spyglass *Spyglass
+
+	once            sync.Once
+	fetcher         ArtifactFetcher
+	resolvedKeyType string
+	resolvedKey     string
+	ttl             time.Duration
+	resolveErr      error
+}
+
+// ParseSrc validates src against the backends registered with s and extracts the job
+// name and build ID from it, returning a uniform "invalid src" error for a src with no
+// key-type, an unregistered key-type, or a malformed prow key. A storage key too short
+// to hold a job name and build ID is accepted with both left blank. It returns a *SrcRef, rather than a SrcRef, since a SrcRef caches
+// its resolved backend behind a sync.Once and so must not be copied.
+func (s *Spyglass) ParseSrc(src string) (*SrcRef, error) {
+	keyType, key, err := splitSrc(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid src %q: %v", src, err)
+	}
+
+	ref := &SrcRef{spyglass: s, KeyType: keyType, Key: key}
+
+	if keyType == prowKeyType {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid src %q: prow key %q incorrectly formatted", src, key)
+		}
+		ref.JobName, ref.BuildID = parts[0], parts[1]
+		return ref, nil
+	}
+
+	if _, err := s.fetcherForKeyType(keyType); err != nil {
+		return nil, fmt.Errorf("invalid src %q: %v", src, err)
+	}
+	// Unlike the prow key-type, a malformed storage key isn't fatal: job/build are only
+	// used for the pod-log fallback, so leave them blank and let the fetch itself fail (or
+	// succeed) on the unparsed key, matching the old splitSrc-based behavior.
+	trimmed := strings.TrimSuffix(key, "/")
+	if parts := strings.Split(trimmed, "/"); len(parts) >= 2 {
+		ref.JobName = parts[len(parts)-2]
+		ref.BuildID = parts[len(parts)-1]
+	}
+	return ref, nil
+}
+
+// Resolve looks up the storage backend, its key type, the key within it, and the cache TTL
+// backing this SrcRef, memoizing the result so a prow src is only ever resolved via a
+// prowjob lookup once. The returned key type is the resolved backend's key type (e.g. "gcs"),
+// not necessarily r.KeyType (e.g. "prow") — callers that cache by (key type, key), such as
+// ArtifactCache, must use this value so that two srcs resolving to the same underlying
+// storage key share one cache entry.
+func (r *SrcRef) Resolve() (ArtifactFetcher, string, string, time.Duration, error) {
+	r.once.Do(func() {
+		r.fetcher, r.resolvedKeyType, r.resolvedKey, r.ttl, r.resolveErr = r.spyglass.fetcherForSrc(r.KeyType, r.Key)
+	})
+	return r.fetcher, r.resolvedKeyType, r.resolvedKey, r.ttl, r.resolveErr
+}
+
+// GCSKey returns the storage key backing this SrcRef, resolving it via a prowjob lookup
+// the first time it's needed and caching the result for the lifetime of the SrcRef.
+func (r *SrcRef) GCSKey() (string, error) {
+	_, _, key, _, err := r.Resolve()
+	return key, err
+}
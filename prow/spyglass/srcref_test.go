@@ -0,0 +1,132 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"testing"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// fakeArtifactFetcher is a minimal ArtifactFetcher used to exercise ParseSrc/Resolve
+// without depending on a real storage backend.
+type fakeArtifactFetcher struct{}
+
+func (fakeArtifactFetcher) artifacts(key string) ([]string, error) { return nil, nil }
+func (fakeArtifactFetcher) artifact(key, name string, sizeLimit int64) (lenses.Artifact, error) {
+	return nil, nil
+}
+
+func TestParseSrc(t *testing.T) {
+	s := &Spyglass{}
+	s.RegisterArtifactFetcher(gcsKeyType, fakeArtifactFetcher{})
+
+	cases := []struct {
+		name        string
+		src         string
+		wantErr     bool
+		wantJobName string
+		wantBuildID string
+	}{
+		{
+			name:        "well formed gcs src",
+			src:         "gcs/bucket/logs/some-job/123",
+			wantJobName: "some-job",
+			wantBuildID: "123",
+		},
+		{
+			name:        "well formed prow src",
+			src:         "prow/some-job/123",
+			wantJobName: "some-job",
+			wantBuildID: "123",
+		},
+		{
+			name:    "malformed prow src is rejected",
+			src:     "prow/some-job/123/extra",
+			wantErr: true,
+		},
+		{
+			name:    "unregistered key type is rejected",
+			src:     "s3/bucket/logs/some-job/123",
+			wantErr: true,
+		},
+		{
+			name:    "missing key-type separator is rejected",
+			src:     "gcs",
+			wantErr: true,
+		},
+		{
+			name: "short gcs key is tolerated, matching the old splitSrc behavior",
+			src:  "gcs/only-one-segment",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, err := s.ParseSrc(tc.src)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseSrc(%q) = %+v, want error", tc.src, ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSrc(%q) returned unexpected error: %v", tc.src, err)
+			}
+			if ref.JobName != tc.wantJobName || ref.BuildID != tc.wantBuildID {
+				t.Errorf("ParseSrc(%q) = {JobName: %q, BuildID: %q}, want {JobName: %q, BuildID: %q}",
+					tc.src, ref.JobName, ref.BuildID, tc.wantJobName, tc.wantBuildID)
+			}
+		})
+	}
+}
+
+func TestParseSrcResolveCachesResult(t *testing.T) {
+	s := &Spyglass{}
+	fetcher := fakeArtifactFetcher{}
+	s.RegisterArtifactFetcher(gcsKeyType, fetcher)
+
+	ref, err := s.ParseSrc("gcs/bucket/logs/some-job/123")
+	if err != nil {
+		t.Fatalf("ParseSrc returned unexpected error: %v", err)
+	}
+
+	gotFetcher, keyType, key, ttl, err := ref.Resolve()
+	if err != nil {
+		t.Fatalf("Resolve returned unexpected error: %v", err)
+	}
+	if gotFetcher != fetcher {
+		t.Errorf("Resolve fetcher = %v, want %v", gotFetcher, fetcher)
+	}
+	if keyType != gcsKeyType {
+		t.Errorf("Resolve keyType = %q, want %q", keyType, gcsKeyType)
+	}
+	if key != "bucket/logs/some-job/123" {
+		t.Errorf("Resolve key = %q, want %q", key, "bucket/logs/some-job/123")
+	}
+	if ttl != finishedArtifactTTL {
+		t.Errorf("Resolve ttl = %v, want %v (gcs srcs are treated as immutable)", ttl, finishedArtifactTTL)
+	}
+
+	// A second Resolve call should return the memoized result rather than re-resolving.
+	// Removing the backend makes a fresh resolve fail, so only a memoized result passes.
+	delete(s.artifactFetchers, gcsKeyType)
+	gotFetcher2, keyType2, key2, ttl2, err2 := ref.Resolve()
+	if err2 != nil || gotFetcher2 != gotFetcher || keyType2 != keyType || key2 != key || ttl2 != ttl {
+		t.Errorf("second Resolve() = (%v, %q, %q, %v, %v), want the same values as the first call", gotFetcher2, keyType2, key2, ttl2, err2)
+	}
+}